@@ -0,0 +1,129 @@
+package main
+
+import "bufio"
+import "fmt"
+import "os"
+import "strconv"
+import "strings"
+import "time"
+
+import "ubtue/logrotate"
+
+// Target describes one entry of a --config file: a glob pattern of log files plus the rotation policy
+// to apply to each of them.
+type Target struct {
+	Glob    string
+	Options logrotate.Options
+}
+
+// loadConfig reads an INI-style config file where each "[name]" section describes one rotation target
+// via "key = value" lines, e.g.:
+//
+//	[zeder]
+//	glob = zeder.log
+//	max-rotation-count = 30
+//	max-age = 720h
+//
+//	[debug]
+//	glob = *debug*.log
+//	max-rotation-count = 5
+//	compress = true
+//	owner = zeder:zeder
+//	mode = 0640
+func loadConfig(configPath string) ([]Target, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []Target
+	var current *Target
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			targets = append(targets, Target{Options: logrotate.Options{MaxBackups: defaultMaxRotationCount}})
+			current = &targets[len(targets)-1]
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%s:%d: key/value line outside of any [section]", configPath, lineNo)
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected \"key = value\"", configPath, lineNo)
+		}
+		if err := setTargetField(current, strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", configPath, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// setTargetField applies a single "key = value" config line to target.
+func setTargetField(target *Target, key, value string) error {
+	switch key {
+	case "glob":
+		target.Glob = value
+	case "max-rotation-count":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n < 1 {
+			return fmt.Errorf("max-rotation-count must be positive")
+		}
+		target.Options.MaxBackups = n
+	case "max-size":
+		size, err := logrotate.ParseSize(value)
+		if err != nil {
+			return err
+		}
+		target.Options.MaxSize = size
+	case "max-age":
+		age, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		target.Options.MaxAge = age
+	case "rotation-interval":
+		interval, err := logrotate.ParseInterval(value)
+		if err != nil {
+			return err
+		}
+		target.Options.RotationInterval = interval
+	case "filename-pattern":
+		target.Options.FilenamePattern = value
+	case "compress":
+		compress, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		target.Options.Compress = compress
+	case "compress-format":
+		if value != "gzip" {
+			return fmt.Errorf("compress-format must be \"gzip\" (zstd is not implemented)")
+		}
+		target.Options.CompressFormat = value
+	case "post-rotate":
+		target.Options.PostRotate = value
+	case "owner":
+		target.Options.Owner = value
+	case "mode":
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return err
+		}
+		target.Options.Mode = os.FileMode(mode)
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return nil
+}
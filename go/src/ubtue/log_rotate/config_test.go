@@ -0,0 +1,79 @@
+package main
+
+import "os"
+import "path/filepath"
+import "testing"
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log_rotate.conf")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaultsMissingMaxRotationCount(t *testing.T) {
+	path := writeConfig(t, "[zeder]\nglob = zeder.log\nmax-age = 720h\n")
+
+	targets, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(targets))
+	}
+	if targets[0].Options.MaxBackups != defaultMaxRotationCount {
+		t.Fatalf("expected MaxBackups to default to %d, got %d", defaultMaxRotationCount, targets[0].Options.MaxBackups)
+	}
+}
+
+func TestLoadConfigRejectsNonPositiveMaxRotationCount(t *testing.T) {
+	path := writeConfig(t, "[zeder]\nglob = zeder.log\nmax-rotation-count = 0\n")
+
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("expected an error for max-rotation-count = 0, got nil")
+	}
+}
+
+func TestLoadConfigRejectsZstdCompressFormat(t *testing.T) {
+	path := writeConfig(t, "[debug]\nglob = *debug*.log\ncompress = true\ncompress-format = zstd\n")
+
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("expected an error for compress-format = zstd, got nil")
+	}
+}
+
+func TestLoadConfigMultipleSections(t *testing.T) {
+	path := writeConfig(t, "[zeder]\nglob = zeder.log\nmax-rotation-count = 30\n\n"+
+		"[debug]\nglob = *debug*.log\nmax-rotation-count = 5\ncompress = true\nowner = zeder:zeder\nmode = 0640\n")
+
+	targets, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if targets[0].Glob != "zeder.log" || targets[0].Options.MaxBackups != 30 {
+		t.Fatalf("unexpected first target: %+v", targets[0])
+	}
+	if targets[1].Glob != "*debug*.log" || targets[1].Options.MaxBackups != 5 || !targets[1].Options.Compress {
+		t.Fatalf("unexpected second target: %+v", targets[1])
+	}
+}
+
+func TestLoadConfigRejectsKeyOutsideSection(t *testing.T) {
+	path := writeConfig(t, "glob = zeder.log\n")
+
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("expected an error for a key/value line outside of any [section], got nil")
+	}
+}
+
+func TestSetTargetFieldRejectsUnknownKey(t *testing.T) {
+	var target Target
+	if err := setTargetField(&target, "bogus", "value"); err == nil {
+		t.Fatal("expected an error for an unknown key, got nil")
+	}
+}
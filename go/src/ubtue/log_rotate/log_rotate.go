@@ -22,22 +22,86 @@ import "fmt"
 import "os"
 import "path"
 import "path/filepath"
-import "strconv"
+import "time"
 
-// Handle command-line arguments.
-func processFlags(maxRotationCount *int) {
+import "ubtue/logrotate"
+
+// defaultMaxRotationCount is used both as the --max-rotation-count flag default and as the
+// max-rotation-count a --config section gets when it doesn't specify one.
+const defaultMaxRotationCount = 5
+
+// Handle command-line arguments.  Returns the path of a --config file, or "" if none was given.
+func processFlags(options *logrotate.Options) string {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s [--max-rotation-count max_rotations] log_base_names\n", path.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "usage: %s [--max-rotation-count max_rotations] [--rotation-interval interval]\n"+
+			"       [--filename-pattern pattern] [--max-age max_age] [--compress] [--compress-format gzip]\n"+
+			"       [--max-size max_size] [--post-rotate command] log_base_names\n"+
+			"   or: %s --config config_file\n", path.Base(os.Args[0]), path.Base(os.Args[0]))
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
-	localMaxRotationCount := flag.Int("max-rotation-count", 5, "The maximum number of log file rotations.")
+	config := flag.String("config", "", "Path to a config file listing multiple rotation targets, each with its own "+
+		"glob pattern and policy.  When given, the values of all other flags and any log_base_names are not used "+
+		"for rotation, though the other flags are still checked for valid values.")
+	maxRotationCount := flag.Int("max-rotation-count", defaultMaxRotationCount, "The maximum number of log file rotations.")
+	rotationInterval := flag.String("rotation-interval", "", "Rotate when the current time bucket differs from the "+
+		"log file's mtime bucket.  Accepts \"hourly\", \"daily\", \"weekly\" or a Go duration string, e.g. \"30m\".")
+	filenamePattern := flag.String("filename-pattern", "", "A strftime-style pattern, e.g. \"%Y-%m-%d\", used to name "+
+		"time-rotated files \"log_base_name.<formatted-time>\" instead of \"log_base_name.N\".")
+	maxAge := flag.String("max-age", "", "Delete rotated files older than this Go duration, e.g. \"720h\".")
+	compress := flag.Bool("compress", false, "Compress rotation generations 2 and up after rotating.")
+	compressFormat := flag.String("compress-format", "gzip", "Compression format to use with --compress. Only \"gzip\" "+
+		"is currently implemented (this GOPATH tree has nowhere to vendor a zstd package from).")
+	maxSize := flag.String("max-size", "", "Only rotate files whose current size is at least this large, e.g. \"100M\".  "+
+		"With --rotation-interval also given, a file is rotated once either threshold is reached.")
+	postRotate := flag.String("post-rotate", "", "Shell command to run after a successful rotation; \"%f\" in the "+
+		"command expands to the log file's base name.")
 	flag.Parse()
-	if *localMaxRotationCount < 1 {
+
+	if *maxRotationCount < 1 {
 		fmt.Fprintf(os.Stderr, "%s: max-rotation-count must be positive!\n", path.Base(os.Args[0]))
 		os.Exit(1)
 	}
-	*maxRotationCount = *localMaxRotationCount
+	options.MaxBackups = *maxRotationCount
+
+	if *rotationInterval != "" {
+		interval, err := logrotate.ParseInterval(*rotationInterval)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid --rotation-interval: %s\n", path.Base(os.Args[0]), err.Error())
+			os.Exit(1)
+		}
+		options.RotationInterval = interval
+	}
+
+	options.FilenamePattern = *filenamePattern
+
+	if *maxAge != "" {
+		age, err := time.ParseDuration(*maxAge)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid --max-age: %s\n", path.Base(os.Args[0]), err.Error())
+			os.Exit(1)
+		}
+		options.MaxAge = age
+	}
+
+	if *compressFormat != "gzip" {
+		fmt.Fprintf(os.Stderr, "%s: --compress-format must be \"gzip\" (zstd is not implemented)!\n", path.Base(os.Args[0]))
+		os.Exit(1)
+	}
+	options.Compress = *compress
+	options.CompressFormat = *compressFormat
+
+	if *maxSize != "" {
+		size, err := logrotate.ParseSize(*maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid --max-size: %s\n", path.Base(os.Args[0]), err.Error())
+			os.Exit(1)
+		}
+		options.MaxSize = size
+	}
+	options.PostRotate = *postRotate
+
+	return *config
 }
 
 // Either return the log file names provided on the command-line, or, if there are none,
@@ -50,30 +114,38 @@ func getLogNames() []string {
 	return logNames
 }
 
-func Exists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+// rotateTargets applies each target's own policy to every file matching its glob pattern.
+func rotateTargets(targets []Target) {
+	for _, target := range targets {
+		names, _ := filepath.Glob(target.Glob)
+		for _, name := range names {
+			target.Options.Filename = name
+			if err := logrotate.Rotate(target.Options); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: failed to rotate %s: %s\n", path.Base(os.Args[0]), name, err.Error())
+			}
+		}
+	}
 }
 
-func processName(logFileName string, maxRotationCount int) {
-	if !Exists(logFileName) {
-		return
-	} else {
-		os.Remove(logFileName + "." + strconv.Itoa(maxRotationCount))
-	}
+func main() {
+	var options logrotate.Options
+	configPath := processFlags(&options)
 
-	for countSuffix := maxRotationCount; countSuffix > 1; countSuffix-- {
-		os.Rename(logFileName+"."+strconv.Itoa(countSuffix-1), logFileName+"."+strconv.Itoa(countSuffix))
+	if configPath != "" {
+		targets, err := loadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to load --config %s: %s\n", path.Base(os.Args[0]), configPath, err.Error())
+			os.Exit(1)
+		}
+		rotateTargets(targets)
+		return
 	}
-	os.Rename(logFileName, logFileName+".1")
-}
 
-func main() {
-	var maxRotationCount int
-	processFlags(&maxRotationCount)
-	fmt.Printf("maxRotationCount = %d\n", maxRotationCount)
-	logNames := getLogNames()
-	for _, name := range logNames {
-		processName(name, maxRotationCount)
+	fmt.Printf("maxRotationCount = %d\n", options.MaxBackups)
+	for _, name := range getLogNames() {
+		options.Filename = name
+		if err := logrotate.Rotate(options); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to rotate %s: %s\n", path.Base(os.Args[0]), name, err.Error())
+		}
 	}
 }
@@ -0,0 +1,91 @@
+package logrotate
+
+import "os"
+import "path/filepath"
+import "strconv"
+import "testing"
+import "time"
+
+// fakeClock is a Clock whose Now() is controlled by the test instead of the wall clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestRotatorRotatesOnMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+
+	rotator := New(Options{Filename: filename, MaxSize: 10, MaxBackups: 5, Clock: clock})
+	defer rotator.Close()
+
+	if _, err := rotator.Write([]byte("12345")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := rotator.Write([]byte("1234567890")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if !exists(filename + ".1") {
+		t.Fatalf("expected %s.1 to exist after crossing MaxSize", filename)
+	}
+	if !exists(filename) {
+		t.Fatalf("expected a fresh %s to exist after rotation", filename)
+	}
+}
+
+func TestRotatorRotatesOnTimeBucket(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	firstBucket := time.Date(2024, 6, 1, 0, 30, 0, 0, time.UTC)
+
+	// Seed the log file directly (not via the Rotator) and pin its mtime to the first bucket, so that
+	// the rollover check below has a known mtime to compare its fake "now" against.
+	if err := os.WriteFile(filename, []byte("first bucket"), 0644); err != nil {
+		t.Fatalf("failed to seed log file: %v", err)
+	}
+	if err := os.Chtimes(filename, firstBucket, firstBucket); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	clock := &fakeClock{now: firstBucket.Add(time.Hour)}
+	rotator := New(Options{Filename: filename, RotationInterval: time.Hour, MaxBackups: 5, Clock: clock})
+	defer rotator.Close()
+	if _, err := rotator.Write([]byte("second bucket")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if !exists(filename + ".1") {
+		t.Fatalf("expected %s.1 to exist after crossing the hourly bucket boundary", filename)
+	}
+}
+
+func TestRotateEnforcesMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+
+	for n := 1; n <= 3; n++ {
+		if err := os.WriteFile(filename+"."+strconv.Itoa(n), []byte("old"), 0644); err != nil {
+			t.Fatalf("failed to seed backup %d: %v", n, err)
+		}
+	}
+	if err := os.WriteFile(filename, []byte("active"), 0644); err != nil {
+		t.Fatalf("failed to seed active log: %v", err)
+	}
+
+	if err := Rotate(Options{Filename: filename, MaxBackups: 3, Clock: clock}); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+
+	if exists(filename + ".4") {
+		t.Fatalf("did not expect a 4th backup generation to be kept")
+	}
+	for n := 1; n <= 3; n++ {
+		if !exists(filename + "." + strconv.Itoa(n)) {
+			t.Fatalf("expected backup generation %d to exist", n)
+		}
+	}
+}